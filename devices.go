@@ -0,0 +1,165 @@
+// RTLAMR - An rtl-sdr receiver for smart meters operating in the 900MHz ISM band.
+// Copyright (C) 2015 Douglas Hall
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/bemasher/rtlamr/idm"
+	"github.com/bemasher/rtlamr/parse"
+	"github.com/bemasher/rtlamr/r900"
+	"github.com/bemasher/rtlamr/scm"
+)
+
+// devices lists the dongles to capture from concurrently, as
+// "index:msgtype" pairs separated by commas, e.g. "0:scm,1:r900". SCM and
+// R900 can't both be received on one 2.4MSPS dongle, so this is how to
+// watch both at once: one dongle per protocol, each opened directly
+// through librtlsdr regardless of -source.
+var devices = flag.String("devices", "", `dongles to capture from concurrently, as "index:msgtype,..." (e.g. "0:scm,1:r900")`)
+
+// deviceSession pairs one tuned dongle with the parser chain decoding
+// its samples.
+type deviceSession struct {
+	sdr *RTLSDRSDR
+	p   parse.Parser
+	fc  parse.FilterChain
+}
+
+// newParser builds the parser for a single message type. It doesn't
+// handle "scm+idm", which fans two parsers out from one device rather
+// than naming a parser of its own.
+func newParser(msgType string) parse.Parser {
+	switch strings.ToLower(msgType) {
+	case "scm":
+		return scm.NewParser(*symbolLength, *decimation)
+	case "idm":
+		return idm.NewParser(*symbolLength, *decimation)
+	case "r900":
+		return r900.NewParser(*symbolLength, *decimation)
+	default:
+		log.Fatalf("Invalid message type: %q\n", msgType)
+		return nil
+	}
+}
+
+// deviceEntry is one "index:msgtype" pair parsed out of -devices.
+type deviceEntry struct {
+	index   int
+	msgType string
+}
+
+// parseDeviceSpec parses -devices' "index:msgtype,..." syntax into one
+// entry per device, without touching any hardware.
+func parseDeviceSpec(spec string) ([]deviceEntry, error) {
+	var entries []deviceEntry
+
+	for _, field := range strings.Split(spec, ",") {
+		parts := strings.SplitN(field, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid -devices entry: %q", field)
+		}
+
+		index, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid device index %q: %w", parts[0], err)
+		}
+
+		entries = append(entries, deviceEntry{index: index, msgType: parts[1]})
+	}
+
+	return entries, nil
+}
+
+// newDeviceSessions parses -devices and opens and tunes one dongle per
+// entry, ready for runSessions to start decoding.
+func newDeviceSessions(spec string) (sessions []*deviceSession) {
+	entries, err := parseDeviceSpec(spec)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var fc parse.FilterChain
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "unique":
+			fc.Add(NewUniqueFilter())
+		case "filterid":
+			fc.Add(meterID)
+		case "filtertype":
+			fc.Add(meterType)
+		}
+	})
+
+	for _, entry := range entries {
+		p := newParser(entry.msgType)
+		if !*quiet {
+			p.Log()
+		}
+
+		sdr := &RTLSDRSDR{DeviceIndex: entry.index}
+		if err := sdr.Connect(); err != nil {
+			log.Fatalf("Error connecting to device %d: %v\n", entry.index, err)
+		}
+		if err := sdr.SetCenterFreq(p.Cfg().CenterFreq); err != nil {
+			log.Fatalf("Error setting center freq on device %d: %v\n", entry.index, err)
+		}
+		if err := sdr.SetSampleRate(uint32(p.Cfg().SampleRate)); err != nil {
+			log.Fatalf("Error setting sample rate on device %d: %v\n", entry.index, err)
+		}
+		if err := sdr.SetGainMode(true); err != nil {
+			log.Fatalf("Error setting gain mode on device %d: %v\n", entry.index, err)
+		}
+
+		sessions = append(sessions, &deviceSession{sdr: sdr, p: p, fc: fc})
+	}
+
+	return sessions
+}
+
+// runSessions drives every configured device concurrently: each gets its
+// own goroutine reading samples into its own pipe and its own looper
+// decoding them, with runLooper's shared encMu keeping the combined
+// output coherent.
+func (rcvr *Receiver) runSessions() {
+	var wg sync.WaitGroup
+	for _, s := range rcvr.sessions {
+		in, out := io.Pipe()
+
+		go func(s *deviceSession, out *io.PipeWriter) {
+			block := make([]byte, 16384)
+			for {
+				n, err := s.sdr.Read(block)
+				if err != nil {
+					return
+				}
+				out.Write(block[:n])
+			}
+		}(s, out)
+
+		wg.Add(1)
+		go runLooper(s.p, s.fc, in, &wg)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,62 @@
+// RTLAMR - An rtl-sdr receiver for smart meters operating in the 900MHz ISM band.
+// Copyright (C) 2015 Douglas Hall
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDeviceSpec(t *testing.T) {
+	got, err := parseDeviceSpec("0:scm,1:r900")
+	if err != nil {
+		t.Fatalf("parseDeviceSpec() error: %v", err)
+	}
+
+	want := []deviceEntry{{index: 0, msgType: "scm"}, {index: 1, msgType: "r900"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDeviceSpec() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseDeviceSpecSingle(t *testing.T) {
+	got, err := parseDeviceSpec("2:idm")
+	if err != nil {
+		t.Fatalf("parseDeviceSpec() error: %v", err)
+	}
+
+	want := []deviceEntry{{index: 2, msgType: "idm"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDeviceSpec() = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseDeviceSpecInvalid(t *testing.T) {
+	cases := []string{
+		"",
+		"scm",
+		"0scm",
+		"x:scm",
+		"0:scm,bad",
+	}
+
+	for _, spec := range cases {
+		if _, err := parseDeviceSpec(spec); err == nil {
+			t.Errorf("parseDeviceSpec(%q) expected error, got nil", spec)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// RTLAMR - An rtl-sdr receiver for smart meters operating in the 900MHz ISM band.
+// Copyright (C) 2015 Douglas Hall
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	rtlsdr "github.com/jpoirier/gortlsdr"
+)
+
+// RTLSDRSDR drives a locally-attached RTL-SDR dongle directly through
+// librtlsdr, for the common single-host case where the overhead of a
+// separate rtl_tcp process and a TCP socket buys nothing.
+type RTLSDRSDR struct {
+	// DeviceIndex is the rtl-sdr device to open, by index as reported by
+	// rtl_eeprom/rtl_test. Defaults to -rtlsdrdevice, but callers driving
+	// more than one dongle (see devices.go) set it directly instead.
+	DeviceIndex int
+
+	// fromFlags is set by RegisterFlags, telling Connect to take
+	// DeviceIndex from -rtlsdrdevice.
+	fromFlags bool
+
+	dev *rtlsdr.Context
+
+	centerFreq uint32
+	tune       tuneFlags
+
+	pipeOut *io.PipeReader
+	pipeIn  *io.PipeWriter
+}
+
+var rtlsdrDeviceIndex = flag.Int("rtlsdrdevice", 0, "rtl-sdr device index (-source=rtlsdr)")
+
+func (s *RTLSDRSDR) RegisterFlags() {
+	s.fromFlags = true
+	s.tune.register()
+}
+
+// HandleFlags applies whichever of -centerfreq/-samplerate/-gain* the
+// user actually passed, the same way rtltcp.SDR does internally; the
+// parser's own defaults fill in anything left unset back in NewReceiver.
+func (s *RTLSDRSDR) HandleFlags() {
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "centerfreq":
+			s.SetCenterFreq(uint32(*s.tune.centerFreq))
+		case "samplerate":
+			s.SetSampleRate(uint32(*s.tune.sampleRate))
+		case "tunergainmode":
+			s.SetGainMode(!*s.tune.tunerGainMode)
+		case "tunergain":
+			s.SetGainMode(false)
+			s.SetTunerGain(int(*s.tune.tunerGain * 10))
+		case "gainbyindex":
+			gains, err := s.dev.GetTunerGains()
+			if err == nil && *s.tune.gainByIndex >= 0 && *s.tune.gainByIndex < len(gains) {
+				s.SetGainMode(false)
+				s.SetTunerGain(gains[*s.tune.gainByIndex])
+			}
+		case "agcmode":
+			s.dev.SetAgcMode(*s.tune.agcMode)
+		}
+	})
+}
+
+// Connect opens the device by index and puts it in the same state
+// rtl_tcp would: manual tuner gain mode with a zero xtal correction,
+// ready for SetCenterFreq/SetSampleRate/SetTunerGain to tune it.
+func (s *RTLSDRSDR) Connect() error {
+	if s.fromFlags {
+		s.DeviceIndex = *rtlsdrDeviceIndex
+	}
+
+	dev, err := rtlsdr.Open(s.DeviceIndex)
+	if err != nil {
+		return fmt.Errorf("error opening rtl-sdr device %d: %w", s.DeviceIndex, err)
+	}
+	s.dev = dev
+
+	if err := s.dev.SetTunerGainMode(true); err != nil {
+		return fmt.Errorf("error setting tuner gain mode: %w", err)
+	}
+
+	if err := s.dev.SetXtalFreq(0, 0); err != nil {
+		return fmt.Errorf("error setting xtal freq: %w", err)
+	}
+
+	return nil
+}
+
+func (s *RTLSDRSDR) SetCenterFreq(freq uint32) error {
+	s.centerFreq = freq
+	return s.dev.SetCenterFreq(int(freq))
+}
+
+func (s *RTLSDRSDR) SetSampleRate(sampleRate uint32) error {
+	return s.dev.SetSampleRate(int(sampleRate))
+}
+
+func (s *RTLSDRSDR) SetGainMode(auto bool) error {
+	return s.dev.SetTunerGainMode(!auto)
+}
+
+func (s *RTLSDRSDR) SetTunerGain(gain int) error {
+	return s.dev.SetTunerGain(gain)
+}
+
+func (s *RTLSDRSDR) CenterFreq() uint32 {
+	return s.centerFreq
+}
+
+func (s *RTLSDRSDR) GainCount() int {
+	gains, err := s.dev.GetTunerGains()
+	if err != nil {
+		return 0
+	}
+	return len(gains)
+}
+
+// Read starts pumping samples from the device into an internal pipe the
+// first time it's called, by which point the device has been tuned, and
+// reads from that pipe thereafter. This lets Receiver.Run treat it just
+// like the rtl_tcp socket it's reading today.
+//
+// It uses ReadAsync2 rather than ReadAsync: ReadAsync's callback is a
+// single package-level variable in gortlsdr, which the library's own
+// docs call out as unsafe with more than one dongle running at once (see
+// -devices in devices.go). ReadAsync2 passes the destination pipe through
+// per call instead, so concurrent sessions don't step on each other.
+func (s *RTLSDRSDR) Read(p []byte) (int, error) {
+	if s.pipeOut == nil {
+		s.pipeOut, s.pipeIn = io.Pipe()
+
+		go func() {
+			err := s.dev.ReadAsync2(func(buf []byte, ctx *rtlsdr.UserCtx) {
+				ctx.CustUserCtx.(*io.PipeWriter).Write(buf)
+			}, &rtlsdr.UserCtx{CustUserCtx: s.pipeIn}, 0, 0)
+			s.pipeIn.CloseWithError(err)
+		}()
+	}
+
+	return s.pipeOut.Read(p)
+}
+
+func (s *RTLSDRSDR) Close() error {
+	if s.dev == nil {
+		return nil
+	}
+	s.dev.CancelAsync()
+	return s.dev.Close()
+}
@@ -31,33 +31,53 @@ import (
 
 	"github.com/bemasher/rtlamr/idm"
 	"github.com/bemasher/rtlamr/parse"
-	"github.com/bemasher/rtlamr/r900"
 	"github.com/bemasher/rtlamr/scm"
-	"github.com/bemasher/rtltcp"
 )
 
 var rcvr Receiver
 
 type Receiver struct {
-	rtltcp.SDR
+	SDR
 	p  parse.Parser
 	q  parse.Parser
 	fc parse.FilterChain
+
+	// sessions, when non-empty, overrides everything above: Receiver
+	// drives one dongle per session instead of the single SDR backend,
+	// see -devices in devices.go.
+	sessions []*deviceSession
 }
 
+// encMu guards encoder/logFile and sampleFile, all of which may be read
+// or written concurrently by more than one looper goroutine
+// (-msgtype=scm+idm, or -devices).
+var encMu sync.Mutex
+
+// meterIDMu guards meterID.UintMap, which -single mutates and checks the
+// length of; the same multi-looper hazard encMu fixes for sampleFile
+// applies here too.
+var meterIDMu sync.Mutex
+
 func (rcvr *Receiver) NewReceiver() {
+	for _, spec := range sinkFlags {
+		sink, err := newSink(spec)
+		if err != nil {
+			log.Fatalf("Error configuring sink %q: %v\n", spec, err)
+		}
+		sinks.Add(sink)
+	}
+
+	if *devices != "" {
+		rcvr.sessions = newDeviceSessions(*devices)
+		return
+	}
+
 	switch strings.ToLower(*msgType) {
-	case "scm":
-		rcvr.p = scm.NewParser(*symbolLength, *decimation)
-	case "idm":
-		rcvr.p = idm.NewParser(*symbolLength, *decimation)
 	case "scm+idm":
 		rcvr.p = idm.NewParser(*symbolLength, *decimation)
 		rcvr.q = scm.NewParser(*symbolLength, *decimation)
-	case "r900":
-		rcvr.p = r900.NewParser(*symbolLength, *decimation)
 	default:
-		log.Fatalf("Invalid message type: %q\n", *msgType)
+		rcvr.p = newParser(*msgType)
 	}
 
 	if !*quiet {
@@ -67,16 +87,16 @@ func (rcvr *Receiver) NewReceiver() {
 		}
 	}
 
-	// Connect to rtl_tcp server.
-	if err := rcvr.Connect(nil); err != nil {
+	// Connect to the SDR backend selected by -source.
+	if err := rcvr.Connect(); err != nil {
 		log.Fatal(err)
 	}
 
 	rcvr.HandleFlags()
 
-	// Tell the user how many gain settings were reported by rtl_tcp.
+	// Tell the user how many gain settings were reported by the SDR.
 	if !*quiet {
-		log.Println("GainCount:", rcvr.SDR.Info.GainCount)
+		log.Println("GainCount:", rcvr.GainCount())
 	}
 
 	centerfreqFlagSet := false
@@ -101,7 +121,7 @@ func (rcvr *Receiver) NewReceiver() {
 
 	// Set some parameters for listening.
 	if centerfreqFlagSet {
-		rcvr.SetCenterFreq(uint32(rcvr.Flags.CenterFreq))
+		rcvr.SetCenterFreq(rcvr.CenterFreq())
 	} else {
 		rcvr.SetCenterFreq(rcvr.p.Cfg().CenterFreq)
 	}
@@ -116,114 +136,152 @@ func (rcvr *Receiver) NewReceiver() {
 	return
 }
 
-func (rcvr *Receiver) Run() {
-	in, out := io.Pipe()
-	in2, out2 := io.Pipe()
+// runLooper decodes blocks read from in with p, logging anything fc lets
+// through, until interrupted, the time limit elapses, or (with -single)
+// every filtered meter ID has been seen.
+func runLooper(p parse.Parser, fc parse.FilterChain, in io.Reader, wg *sync.WaitGroup) {
+	defer wg.Done()
 
-	go func() {
-		tcpBlock := make([]byte, 16384)
-		for {
-			n, err := rcvr.Read(tcpBlock)
+	start := time.Now()
+
+	// Setup signal channel for interruption.
+	sigint := make(chan os.Signal, 1)
+	signal.Notify(sigint, os.Kill, os.Interrupt)
+
+	// Setup time limit channel
+	tLimit := make(<-chan time.Time, 1)
+	if *timeLimit != 0 {
+		tLimit = time.After(*timeLimit)
+	}
+
+	block := make([]byte, p.Cfg().BlockSize2)
+	for {
+		// Exit on interrupt or time limit, otherwise receive.
+		select {
+		case <-sigint:
+			return
+		case <-tLimit:
+			fmt.Println("Time Limit Reached:", time.Since(start))
+			return
+		default:
+			// Read new sample block.
+			_, err := io.ReadFull(in, block)
 			if err != nil {
-				return
+				log.Fatal("Error reading samples: ", err)
 			}
-			out.Write(tcpBlock[:n])
-			if(rcvr.q != nil) {
-				out2.Write(tcpBlock[:n])
-			}
-		}
-	}()
 
-	var wg sync.WaitGroup
-	start := time.Now()
-	looper := func(p parse.Parser, fc parse.FilterChain, in io.Reader) {
-		// Setup signal channel for interruption.
-		sigint := make(chan os.Signal, 1)
-		signal.Notify(sigint, os.Kill, os.Interrupt)
-
-		// Setup time limit channel
-		tLimit := make(<-chan time.Time, 1)
-		if *timeLimit != 0 {
-			tLimit = time.After(*timeLimit)
-		}
+			pktFound := false
+			indices := p.Dec().Decode(block)
 
-		block := make([]byte, p.Cfg().BlockSize2)
-		for {
-			// Exit on interrupt or time limit, otherwise receive.
-			select {
-			case <-sigint:
-				return
-			case <-tLimit:
-				fmt.Println("Time Limit Reached:", time.Since(start))
-				return
-			default:
-				// Read new sample block.
-				_, err := io.ReadFull(in, block)
-				if err != nil {
-					log.Fatal("Error reading samples: ", err)
+			for _, pkt := range p.Parse(indices) {
+				if !fc.Match(pkt) {
+					continue
 				}
 
-				pktFound := false
-				indices := p.Dec().Decode(block)
-
-				for _, pkt := range p.Parse(indices) {
-					if !fc.Match(pkt) {
-						continue
-					}
+				var msg parse.LogMessage
+				msg.Time = time.Now()
+				msg.Length = p.Cfg().BufferLength << 1
+				msg.Message = pkt
 
-					var msg parse.LogMessage
-					msg.Time = time.Now()
-					msg.Offset, _ = sampleFile.Seek(0, os.SEEK_CUR)
-					msg.Length = p.Cfg().BufferLength << 1
-					msg.Message = pkt
+				encMu.Lock()
+				msg.Offset, _ = sampleFile.Seek(0, os.SEEK_CUR)
+				err = encoder.Encode(msg)
+				if err != nil {
+					encMu.Unlock()
+					log.Fatal("Error encoding message: ", err)
+				}
 
-					err = encoder.Encode(msg)
-					if err != nil {
-						log.Fatal("Error encoding message: ", err)
-					}
+				// The XML encoder doesn't write new lines after each
+				// element, add them.
+				if _, ok := encoder.(*xml.Encoder); ok {
+					fmt.Fprintln(logFile)
+				}
+				encMu.Unlock()
 
-					// The XML encoder doesn't write new lines after each
-					// element, add them.
-					if _, ok := encoder.(*xml.Encoder); ok {
-						fmt.Fprintln(logFile)
-					}
+				sinks.Publish(msg)
 
-					pktFound = true
-					if *single {
-						if len(meterID.UintMap) == 0 {
-							break
-						} else {
-							delete(meterID.UintMap, uint(pkt.MeterID()))
-						}
+				pktFound = true
+				if *single {
+					meterIDMu.Lock()
+					if len(meterID.UintMap) == 0 {
+						meterIDMu.Unlock()
+						break
 					}
+					delete(meterID.UintMap, uint(pkt.MeterID()))
+					meterIDMu.Unlock()
 				}
+			}
 
-				if pktFound {
-					if *sampleFilename != os.DevNull {
-						_, err = sampleFile.Write(p.Dec().IQ)
-						if err != nil {
-							log.Fatal("Error writing raw samples to file:", err)
-						}
+			if pktFound {
+				if *sampleFilename != os.DevNull {
+					encMu.Lock()
+					_, err = sampleFile.Write(p.Dec().IQ)
+					encMu.Unlock()
+					if err != nil {
+						log.Fatal("Error writing raw samples to file:", err)
 					}
-					if *single && len(meterID.UintMap) == 0 {
+				}
+				if *single {
+					meterIDMu.Lock()
+					empty := len(meterID.UintMap) == 0
+					meterIDMu.Unlock()
+					if empty {
 						return
 					}
 				}
 			}
 		}
 	}
+}
+
+func (rcvr *Receiver) Run() {
+	if len(rcvr.sessions) > 0 {
+		rcvr.runSessions()
+		return
+	}
+
+	in, out := io.Pipe()
+	in2, out2 := io.Pipe()
+
+	go func() {
+		tcpBlock := make([]byte, 16384)
+		for {
+			n, err := rcvr.Read(tcpBlock)
+			if err != nil {
+				return
+			}
+			out.Write(tcpBlock[:n])
+			if(rcvr.q != nil) {
+				out2.Write(tcpBlock[:n])
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
 
 	wg.Add(1);
-	go looper(rcvr.p, rcvr.fc, in);
+	go runLooper(rcvr.p, rcvr.fc, in, &wg);
 	if(rcvr.q != nil) {
 		wg.Add(1);
-		go looper(rcvr.q, rcvr.fc, in2);
+		go runLooper(rcvr.q, rcvr.fc, in2, &wg);
 	}
 
 	wg.Wait();
 
 }
 
+// Close shuts down every device session, or the single SDR backend if
+// -devices wasn't used.
+func (rcvr *Receiver) Close() error {
+	if len(rcvr.sessions) > 0 {
+		for _, s := range rcvr.sessions {
+			s.sdr.Close()
+		}
+		return nil
+	}
+	return rcvr.SDR.Close()
+}
+
 func init() {
 	log.SetFlags(log.Lshortfile | log.Lmicroseconds)
 }
@@ -231,6 +289,7 @@ func init() {
 var cpuprofile = flag.String("cpuprofile", "", "write cpu profile to this file")
 
 func main() {
+	rcvr.SDR = NewSDR()
 	rcvr.RegisterFlags()
 	RegisterFlags()
 
@@ -242,6 +301,7 @@ func main() {
 	defer logFile.Close()
 	defer sampleFile.Close()
 	defer rcvr.Close()
+	defer sinks.Close()
 
 	if *cpuprofile != "" {
 		f, err := os.Create(*cpuprofile)
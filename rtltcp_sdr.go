@@ -0,0 +1,57 @@
+// RTLAMR - An rtl-sdr receiver for smart meters operating in the 900MHz ISM band.
+// Copyright (C) 2015 Douglas Hall
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"log"
+
+	"github.com/bemasher/rtltcp"
+)
+
+// RTLTCPSDR drives an rtl_tcp server over the network. It's the original
+// and default backend, and remains the right choice when the dongle is
+// attached to a different host than rtlamr runs on, or shared between
+// multiple processes.
+type RTLTCPSDR struct {
+	rtltcp.SDR
+}
+
+// Connect dials rtl_tcp at the address given by the embedded SDR's own
+// flags, or the default address if none was set.
+func (s *RTLTCPSDR) Connect() error {
+	return s.SDR.Connect(nil)
+}
+
+// HandleFlags wraps the embedded SDR's HandleFlags, which reports an
+// error SDR.HandleFlags doesn't have room for.
+func (s *RTLTCPSDR) HandleFlags() {
+	if err := s.SDR.HandleFlags(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (s *RTLTCPSDR) SetTunerGain(gain int) error {
+	return s.SDR.SetGain(uint32(gain))
+}
+
+func (s *RTLTCPSDR) CenterFreq() uint32 {
+	return uint32(s.Flags.CenterFreq)
+}
+
+func (s *RTLTCPSDR) GainCount() int {
+	return int(s.Info.GainCount)
+}
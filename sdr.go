@@ -0,0 +1,89 @@
+// RTLAMR - An rtl-sdr receiver for smart meters operating in the 900MHz ISM band.
+// Copyright (C) 2015 Douglas Hall
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"strings"
+)
+
+// SDR is the interface a sample source must implement to be driven by
+// Receiver. It covers both tuning a device and registering/handling its
+// own command line flags, so Receiver never needs to know which backend
+// is actually in use.
+type SDR interface {
+	RegisterFlags()
+	HandleFlags()
+
+	Connect() error
+	SetCenterFreq(freq uint32) error
+	SetSampleRate(sampleRate uint32) error
+	SetGainMode(auto bool) error
+	SetTunerGain(gain int) error
+
+	// CenterFreq and GainCount report the backend's current tuned
+	// frequency and number of discrete gain settings it reported, used
+	// by Receiver as defaults and status output.
+	CenterFreq() uint32
+	GainCount() int
+
+	Read(p []byte) (int, error)
+	Close() error
+}
+
+// source selects which SDR backend NewSDR builds. It's declared here so
+// -h documents it, though its value is read from argv directly: the
+// backend has to be chosen, and its flags registered, before flag.Parse
+// runs, otherwise only one backend could ever register flag names like
+// -centerfreq without colliding with the other.
+var source = flag.String("source", "rtltcp", "sdr backend to use: rtltcp, rtlsdr, file")
+
+// NewSDR builds the SDR backend named by -source. It must be called
+// before flag.Parse so only the selected backend's flags are registered.
+func NewSDR() SDR {
+	switch strings.ToLower(sourceArg()) {
+	case "", "rtltcp":
+		return new(RTLTCPSDR)
+	case "rtlsdr":
+		return new(RTLSDRSDR)
+	case "file":
+		return new(FileSDR)
+	default:
+		log.Fatalf("Invalid source: %q\n", sourceArg())
+		return nil
+	}
+}
+
+// sourceArg scans argv for -source/--source directly, since its value is
+// needed to pick a backend before flag.Parse has run.
+func sourceArg() string {
+	for i, arg := range os.Args {
+		switch {
+		case arg == "-source" || arg == "--source":
+			if i+1 < len(os.Args) {
+				return os.Args[i+1]
+			}
+		case strings.HasPrefix(arg, "-source="):
+			return strings.TrimPrefix(arg, "-source=")
+		case strings.HasPrefix(arg, "--source="):
+			return strings.TrimPrefix(arg, "--source=")
+		}
+	}
+	return "rtltcp"
+}
@@ -0,0 +1,47 @@
+// RTLAMR - An rtl-sdr receiver for smart meters operating in the 900MHz ISM band.
+// Copyright (C) 2015 Douglas Hall
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSourceArg(t *testing.T) {
+	orig := os.Args
+	defer func() { os.Args = orig }()
+
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"rtlamr"}, "rtltcp"},
+		{[]string{"rtlamr", "-source", "rtlsdr"}, "rtlsdr"},
+		{[]string{"rtlamr", "-source=file"}, "file"},
+		{[]string{"rtlamr", "--source", "rtlsdr"}, "rtlsdr"},
+		{[]string{"rtlamr", "--source=file"}, "file"},
+		{[]string{"rtlamr", "-msgtype", "scm", "-source", "rtlsdr"}, "rtlsdr"},
+		{[]string{"rtlamr", "-source"}, "rtltcp"},
+	}
+
+	for _, c := range cases {
+		os.Args = c.args
+		if got := sourceArg(); got != c.want {
+			t.Errorf("sourceArg() with args %v = %q, want %q", c.args, got, c.want)
+		}
+	}
+}
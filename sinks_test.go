@@ -0,0 +1,56 @@
+// RTLAMR - An rtl-sdr receiver for smart meters operating in the 900MHz ISM band.
+// Copyright (C) 2015 Douglas Hall
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/bemasher/rtlamr/parse"
+)
+
+type fakeMessage struct {
+	id uint32
+}
+
+func (m fakeMessage) MeterID() uint32 { return m.id }
+
+func TestMeterTypeName(t *testing.T) {
+	msg := parse.LogMessage{Message: fakeMessage{id: 1}}
+	if got, want := meterTypeName(msg), "fakeMessage"; got != want {
+		t.Errorf("meterTypeName() = %q, want %q", got, want)
+	}
+}
+
+func TestRenderTopic(t *testing.T) {
+	msg := parse.LogMessage{Message: fakeMessage{id: 42}}
+
+	got := renderTopic("rtlamr/{meterType}/{meterID}", msg)
+	want := "rtlamr/fakeMessage/42"
+	if got != want {
+		t.Errorf("renderTopic() = %q, want %q", got, want)
+	}
+}
+
+func TestRenderTopicNoPlaceholders(t *testing.T) {
+	msg := parse.LogMessage{Message: fakeMessage{id: 42}}
+
+	got := renderTopic("rtlamr/fixed", msg)
+	want := "rtlamr/fixed"
+	if got != want {
+		t.Errorf("renderTopic() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,43 @@
+// RTLAMR - An rtl-sdr receiver for smart meters operating in the 900MHz ISM band.
+// Copyright (C) 2015 Douglas Hall
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Affero General Public License as published
+// by the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Affero General Public License for more details.
+//
+// You should have received a copy of the GNU Affero General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import "flag"
+
+// tuneFlags are the generic -centerfreq/-samplerate/-gain* flags used by
+// SDR backends that don't bring their own, unlike RTLTCPSDR's embedded
+// rtltcp.SDR. They're registered under the same names so switching
+// -source doesn't change which of these flags are available. Only one backend's
+// RegisterFlags ever runs, so reusing these names across backends never
+// collides with rtltcp.SDR's own registration.
+type tuneFlags struct {
+	centerFreq    *uint
+	sampleRate    *uint
+	gainByIndex   *int
+	tunerGainMode *bool
+	tunerGain     *float64
+	agcMode       *bool
+}
+
+func (t *tuneFlags) register() {
+	t.centerFreq = flag.Uint("centerfreq", 0, "center frequency to receive on")
+	t.sampleRate = flag.Uint("samplerate", 0, "sample rate")
+	t.gainByIndex = flag.Int("gainbyindex", -1, "set gain by index into the reported gain table")
+	t.tunerGainMode = flag.Bool("tunergainmode", false, "enable manual tuner gain")
+	t.tunerGain = flag.Float64("tunergain", 0, "set tuner gain in dB, implies -tunergainmode")
+	t.agcMode = flag.Bool("agcmode", false, "enable rtl agc")
+}